@@ -0,0 +1,104 @@
+// Package httpclient builds the single, polite HTTP client nagibackup
+// uses for every request: page fetches and image downloads alike carry
+// a configurable User-Agent, are rate limited per host, and can
+// optionally be checked against the target host's robots.txt.
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/time/rate"
+)
+
+// DefaultUserAgent identifies this tool and its version when none is
+// configured explicitly.
+const DefaultUserAgent = "nagibackup/1.0 (+https://github.com/dullgiulio/nagibackup)"
+
+// New returns an *http.Client that injects userAgent into every
+// request, rate limits requests per host to rps requests/second (zero
+// disables limiting), and, if honorRobots is set, refuses requests
+// disallowed by the target host's robots.txt.
+func New(userAgent string, rps float64, honorRobots bool) *http.Client {
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	return &http.Client{
+		Transport: &transport{
+			base:        http.DefaultTransport,
+			userAgent:   userAgent,
+			rps:         rps,
+			limiters:    make(map[string]*rate.Limiter),
+			honorRobots: honorRobots,
+			robots:      make(map[string]*robotRules),
+		},
+	}
+}
+
+// Document fetches url through client and parses the response body
+// with goquery. The request is bound to ctx, so a cancelled ctx aborts
+// the fetch instead of letting a crawl keep walking pages after
+// shutdown.
+func Document(ctx context.Context, client *http.Client, url string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
+// transport wraps a base RoundTripper to add a User-Agent, per-host
+// rate limiting, and robots.txt enforcement around every request.
+type transport struct {
+	base      http.RoundTripper
+	userAgent string
+
+	rps      float64
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+
+	honorRobots bool
+	robotsMu    sync.Mutex
+	robots      map[string]*robotRules
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.honorRobots && !t.allowed(req) {
+		return nil, fmt.Errorf("robots.txt disallows %s", req.URL)
+	}
+
+	if t.rps > 0 {
+		if err := t.limiter(req.URL.Host).Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	cloned := req.Clone(req.Context())
+	cloned.Header.Set("User-Agent", t.userAgent)
+
+	return t.base.RoundTrip(cloned)
+}
+
+func (t *transport) limiter(host string) *rate.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, ok := t.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(t.rps), 1)
+		t.limiters[host] = l
+	}
+
+	return l
+}
@@ -0,0 +1,94 @@
+package httpclient
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+)
+
+// robotRules is a minimal robots.txt representation: the Disallow
+// prefixes that apply to the "*" user-agent.
+type robotRules struct {
+	disallow []string
+}
+
+func (r *robotRules) allows(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	for _, prefix := range r.disallow {
+		if prefix != "" && strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allowed reports whether req is permitted by the cached robots.txt of
+// req.URL's host, fetching and parsing it on first use. An unreachable
+// robots.txt fails open ("allow all").
+func (t *transport) allowed(req *http.Request) bool {
+	host := req.URL.Host
+
+	t.robotsMu.Lock()
+	rules, ok := t.robots[host]
+	t.robotsMu.Unlock()
+
+	if !ok {
+		rules = fetchRobots(t.base, t.userAgent, req.URL.Scheme, host)
+
+		t.robotsMu.Lock()
+		t.robots[host] = rules
+		t.robotsMu.Unlock()
+	}
+
+	return rules.allows(req.URL.Path)
+}
+
+// fetchRobots retrieves and parses /robots.txt for host, using base
+// directly so the fetch itself isn't subject to robots checking, but
+// still carrying userAgent so sites that reject anonymous clients
+// don't silently fail the check open.
+func fetchRobots(base http.RoundTripper, userAgent, scheme, host string) *robotRules {
+	rules := &robotRules{}
+
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+host+"/robots.txt", nil)
+	if err != nil {
+		return rules
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return rules
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return rules
+	}
+
+	relevant := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lower := strings.ToLower(line)
+
+		switch {
+		case strings.HasPrefix(lower, "user-agent:"):
+			relevant = strings.TrimSpace(line[len("user-agent:"):]) == "*"
+		case relevant && strings.HasPrefix(lower, "disallow:"):
+			rules.disallow = append(rules.disallow, strings.TrimSpace(line[len("disallow:"):]))
+		}
+	}
+
+	return rules
+}
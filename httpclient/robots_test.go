@@ -0,0 +1,99 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRobotRulesAllows(t *testing.T) {
+	rules := &robotRules{disallow: []string{"/private", "/tmp/"}}
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"empty path treated as root", "", true},
+		{"root allowed", "/", true},
+		{"unrelated path allowed", "/public/image.jpg", true},
+		{"disallowed prefix", "/private/image.jpg", false},
+		{"disallowed prefix exact", "/private", false},
+		{"another disallowed prefix", "/tmp/scratch", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.allows(tt.path); got != tt.want {
+				t.Errorf("allows(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotRulesAllowsEmptyDisallowEntry(t *testing.T) {
+	// A blank "Disallow:" line means "disallow nothing" per the robots.txt
+	// spec, not "disallow everything" via an empty-string prefix match.
+	rules := &robotRules{disallow: []string{""}}
+
+	if !rules.allows("/anything") {
+		t.Error("allows(\"/anything\") = false, want true for a blank Disallow entry")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestFetchRobotsParsesRelevantUserAgent(t *testing.T) {
+	body := "User-agent: googlebot\n" +
+		"Disallow: /googleonly\n" +
+		"\n" +
+		"# comment line\n" +
+		"User-agent: *\n" +
+		"Disallow: /private\n" +
+		"Disallow: /tmp/\n"
+
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if got := req.Header.Get("User-Agent"); got != "test-agent" {
+			t.Errorf("robots.txt fetch User-Agent = %q, want %q", got, "test-agent")
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	rules := fetchRobots(base, "test-agent", "http", "example.com")
+
+	if rules.allows("/private/x") {
+		t.Error("expected /private/x to be disallowed")
+	}
+
+	if !rules.allows("/googleonly") {
+		t.Error("rules for a different user-agent must not apply to us")
+	}
+
+	if !rules.allows("/public") {
+		t.Error("expected /public to be allowed")
+	}
+}
+
+func TestFetchRobotsFailsOpenOnNon200(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+
+	rules := fetchRobots(base, "test-agent", "http", "example.com")
+
+	if !rules.allows("/anything") {
+		t.Error("expected an unreachable/missing robots.txt to allow everything")
+	}
+}
@@ -0,0 +1,177 @@
+// Package imgur implements provider.Provider for dumping an imgur
+// subreddit: it pages through the subreddit's "new" listing and expands
+// any album it finds into its individual images.
+package imgur
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dullgiulio/nagibackup/httpclient"
+	"github.com/dullgiulio/nagibackup/provider"
+)
+
+// DefaultDomain is the imgur site the subreddit listing pages live on.
+const DefaultDomain = "https://imgur.com"
+
+// Host is the hostname this provider handles.
+const Host = "imgur.com"
+
+// Provider archives all images (including album contents) posted to a
+// single imgur subreddit.
+type Provider struct {
+	Domain  string
+	Verbose bool
+
+	client *http.Client
+}
+
+// New returns a Provider configured for the default imgur.com domain,
+// fetching pages and albums through client. When verbose is set, the
+// provider logs page-fetch and link-extraction tracing.
+func New(client *http.Client, verbose bool) *Provider {
+	return &Provider{Domain: DefaultDomain, Verbose: verbose, client: client}
+}
+
+func (p *Provider) document(ctx context.Context, url string) (*goquery.Document, error) {
+	return httpclient.Document(ctx, p.client, url)
+}
+
+// Pages yields "/r/{sub}/new/page/N/hit" listing pages, starting from
+// startURL (which is expected to name the subreddit, e.g.
+// "https://imgur.com/r/wallpapers"), incrementing the page number until
+// a page comes back with no entries or ctx is cancelled.
+func (p *Provider) Pages(ctx context.Context, startURL string) <-chan string {
+	pages := make(chan string)
+
+	go func() {
+		defer close(pages)
+
+		sub := strings.TrimSuffix(startURL, "/")
+
+		for n := 0; ; n++ {
+			if ctx.Err() != nil {
+				return
+			}
+
+			pageURL := fmt.Sprintf("%s/new/page/%d/hit", sub, n)
+
+			if p.Verbose {
+				log.Print("Fetching links from ", pageURL)
+			}
+
+			doc, err := p.document(ctx, pageURL)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Print("Error opening subreddit listing page: ", err.Error())
+				}
+				return
+			}
+
+			if doc.Find("div.post").Length() == 0 {
+				return
+			}
+
+			select {
+			case pages <- pageURL:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return pages
+}
+
+// ImagesOnPage returns one ImageRef per image on the listing page,
+// expanding any album post into its individual images.
+func (p *Provider) ImagesOnPage(ctx context.Context, url string) []provider.ImageRef {
+	doc, err := p.document(ctx, url)
+	if err != nil {
+		log.Print("Error opening subreddit listing page: ", err.Error())
+		return nil
+	}
+
+	var refs []provider.ImageRef
+
+	doc.Find("div.post").Each(func(i int, s *goquery.Selection) {
+		hash, ok := s.Attr("data-id")
+		if !ok {
+			if p.Verbose {
+				log.Print("Post without data-id, skipping")
+			}
+			return
+		}
+
+		if _, isAlbum := s.Attr("data-is-album"); isAlbum {
+			refs = append(refs, p.albumRefs(ctx, hash)...)
+			return
+		}
+
+		refs = append(refs, provider.ImageRef{
+			Name: hash,
+			URL:  fmt.Sprintf("https://i.imgur.com/%s.jpg", hash),
+		})
+	})
+
+	return refs
+}
+
+type albumImage struct {
+	Hash string `json:"hash"`
+	Ext  string `json:"ext"`
+}
+
+type albumImagesResponse struct {
+	Data struct {
+		Images []albumImage `json:"images"`
+	} `json:"data"`
+}
+
+// albumRefs expands an album hash into the ImageRefs of its images via
+// imgur's ajax endpoint.
+func (p *Provider) albumRefs(ctx context.Context, hash string) []provider.ImageRef {
+	apiURL := p.Domain + "/ajaxalbums/getimages/" + hash + "/hit.json"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		log.Print("Error building album images request: ", err.Error())
+		return nil
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Print("Error fetching album images: ", err.Error())
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var parsed albumImagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		log.Print("Error decoding album images: ", err.Error())
+		return nil
+	}
+
+	refs := make([]provider.ImageRef, 0, len(parsed.Data.Images))
+
+	for i, img := range parsed.Data.Images {
+		refs = append(refs, provider.ImageRef{
+			Name: hash + "-" + strconv.Itoa(i),
+			URL:  fmt.Sprintf("https://i.imgur.com/%s%s", img.Hash, img.Ext),
+		})
+	}
+
+	return refs
+}
+
+// ResolveImage is a no-op for imgur: ImagesOnPage already produces the
+// final i.imgur.com URL for every image and album entry.
+func (p *Provider) ResolveImage(ctx context.Context, ref provider.ImageRef) (string, error) {
+	return ref.URL, nil
+}
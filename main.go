@@ -1,216 +1,178 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
 	"strings"
 	"sync"
-
-	"github.com/PuerkitoBio/goquery"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dullgiulio/nagibackup/httpclient"
+	"github.com/dullgiulio/nagibackup/imgur"
+	"github.com/dullgiulio/nagibackup/manifest"
+	"github.com/dullgiulio/nagibackup/nagi"
+	"github.com/dullgiulio/nagibackup/progress"
+	"github.com/dullgiulio/nagibackup/provider"
+	"github.com/dullgiulio/nagibackup/transfer"
 )
 
 type conf struct {
-	directory     string
-	url           string
-	verbose       bool
-	dryrun        bool
-	size          int
-	parallel      int
-	defaultDomain string
+	directory string
+	url       string
+	provider  string
+	verbose   bool
+	dryrun    bool
+	silent    bool
+	size      int
+	parallel  int
+	maxBytes  int64
+	userAgent string
+	rate      float64
+	robots    bool
 }
 
 // This is not thread safe as it is only read after init.
 var config conf
-var semaphore chan struct{}
+var manager *transfer.Manager
+var manifestStore *manifest.Store
+var client *http.Client
+
+// providers maps a URL host to the Provider that knows how to archive
+// it. Add an entry here whenever a new site-specific implementation is
+// added.
+var providers = map[string]func(*http.Client, bool) provider.Provider{
+	nagi.Host:  func(c *http.Client, verbose bool) provider.Provider { return nagi.New(c, verbose) },
+	imgur.Host: func(c *http.Client, verbose bool) provider.Provider { return imgur.New(c, verbose) },
+}
 
-func downloadImage(url string) {
-	destination := path.Join(config.directory, path.Base(url))
+// selectProvider picks a Provider for targetURL. An explicit
+// --provider flag always wins; otherwise the URL's host is used.
+func selectProvider(name, targetURL string) provider.Provider {
+	if name == "" {
+		u, err := url.Parse(targetURL)
+		if err != nil {
+			log.Fatal("Invalid URL: ", err)
+		}
 
-	if config.verbose {
-		log.Print("Saving into ", destination)
+		name = strings.TrimPrefix(u.Host, "www.")
 	}
 
-	out, err := os.Create(destination)
-	if err != nil {
-		log.Fatal(err)
+	newProvider, ok := providers[name]
+	if !ok {
+		log.Fatal("No provider available for ", name)
 	}
 
-	defer out.Close()
+	return newProvider(client, config.verbose)
+}
+
+func downloadRef(ctx context.Context, p provider.Provider, ref provider.ImageRef, wg *sync.WaitGroup) {
+	defer wg.Done()
 
-	resp, err := http.Get(url)
+	imageURL, err := p.ResolveImage(ctx, ref)
 	if err != nil {
-		log.Print("Error in HTTP GET: ", err.Error())
+		log.Print("Error resolving image: ", err.Error())
 		return
 	}
-	defer resp.Body.Close()
 
-	if config.verbose {
-		log.Print("Downloading ", url)
+	if imageURL == "" {
+		return
 	}
 
-	if _, err = io.Copy(out, resp.Body); err != nil {
-		log.Print("Error in image download: ", err.Error())
+	if _, ok := manifestStore.Lookup(imageURL); ok {
+		if config.verbose {
+			log.Print("Already downloaded, skipping ", imageURL)
+		}
+		return
 	}
 
-	if err := out.Sync(); err != nil {
-		log.Print("Error in image save: ", err.Error())
+	if config.verbose {
+		log.Print("Downloading ", imageURL)
 	}
-}
 
-func getActualImageUrl(url string) (image string) {
-	doc, err := goquery.NewDocument(url)
+	result, err := manager.Download(ctx, imageURL)
 	if err != nil {
-		log.Print("Error opening document for single image: ", err.Error())
+		log.Print("Error in image download: ", err.Error())
 		return
 	}
 
-	doc.Find("div table").Each(func(i int, s *goquery.Selection) {
-		el := s.Parent().Find("img")
-		if el == nil {
-			return
-		}
-
-		if val, ok := el.Attr("src"); ok {
-			image = val
-		}
-	})
-
-	return
-}
-
-func downloadActualImage(url string, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	imageUrl := getActualImageUrl(url)
-	if imageUrl != "" {
-		downloadImage(imageUrl)
+	entry := manifest.Entry{
+		SHA256:    result.SHA256,
+		Size:      result.Size,
+		Filename:  path.Base(result.Path),
+		FetchedAt: time.Now(),
 	}
 
-	if config.parallel > 0 {
-		// Unblock next resource.
-		<-semaphore
+	if err := manifestStore.Record(imageURL, entry); err != nil {
+		log.Print("Error recording manifest entry: ", err.Error())
 	}
 }
 
-func downloadImages(urls <-chan string, wg *sync.WaitGroup) {
+func downloadImages(ctx context.Context, p provider.Provider, pages <-chan string, renderer *progress.Renderer, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	for url := range urls {
-		doc, err := goquery.NewDocument(url)
-		if err != nil {
-			log.Print("Error opening document with all images: ", err.Error())
-			continue
-		}
-
-		doc.Find("#zoom ul li a").Each(func(i int, s *goquery.Selection) {
-			val, ok := s.Attr("href")
+	for {
+		select {
+		case pageURL, ok := <-pages:
 			if !ok {
-				if config.verbose {
-					log.Print("Href not found, skipping")
-				}
-
 				return
 			}
 
-			// TODO: Select based on setting in config.size
-			if !strings.Contains(val, "size=o") {
-				return
-			}
+			refs := p.ImagesOnPage(ctx, pageURL)
+			renderer.Discovered(len(refs))
 
-			if config.parallel > 0 {
-				// Blocks until one slot out of config.parallel is free.
-				semaphore <- struct{}{}
+			for _, ref := range refs {
+				wg.Add(1)
+				go downloadRef(ctx, p, ref, wg)
 			}
-
-			wg.Add(1)
-			go downloadActualImage(config.defaultDomain+val, wg)
-		})
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-func printImages(urls <-chan string, wg *sync.WaitGroup) {
+func printImages(ctx context.Context, p provider.Provider, pages <-chan string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	for url := range urls {
-		fmt.Printf("%s\n", url)
-	}
-}
-
-func extractImageUrls(urls chan<- string, doc *goquery.Document) {
-	doc.Find("div.imagelog p a").Each(func(i int, s *goquery.Selection) {
-		val, ok := s.Attr("href")
-		if !ok {
-			if config.verbose {
-				log.Fatal("Href not found")
+	for {
+		select {
+		case pageURL, ok := <-pages:
+			if !ok {
+				return
 			}
 
+			for _, ref := range p.ImagesOnPage(ctx, pageURL) {
+				fmt.Printf("%s\n", ref.URL)
+			}
+		case <-ctx.Done():
 			return
 		}
-
-		urls <- val
-	})
-}
-
-func extractNextUrl(doc *goquery.Document) (nextUrl string) {
-	doc.Find("div.pager a.navi").Each(func(i int, s *goquery.Selection) {
-		val, ok := s.Attr("id")
-		if !ok {
-			return
-		}
-
-		if !strings.HasPrefix(val, "next_pager_") {
-			return
-		}
-
-		pageNextUrl, ok := s.Attr("href")
-		if !ok {
-			log.Print("Invalid pager link")
-		}
-
-		nextUrl = config.defaultDomain + pageNextUrl
-	})
-
-	return
-}
-
-func fetchAllImageUrls(url string, urls chan<- string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	defer close(urls)
-
-	for url != "" {
-		if config.verbose {
-			log.Print("Fetching links from ", url)
-		}
-
-		doc, err := goquery.NewDocument(url)
-		if err != nil {
-			log.Fatal("Error opening document with single image: ", err)
-		}
-
-		extractImageUrls(urls, doc)
-
-		if nextUrl := extractNextUrl(doc); url == nextUrl {
-			break
-		} else {
-			url = nextUrl
-		}
 	}
 }
 
 func initDefaults() {
-	config.defaultDomain = "http://nagi.ee"
 	//config.size = Original
 }
 
 func parseArgs() {
 	flag.IntVar(&config.parallel, "parallel", 4, "How many parallel downloads to perform; use zero to disable")
+	flag.StringVar(&config.provider, "provider", "", "Site provider to use (nagi.ee, imgur.com); defaults to the URL's host")
 	flag.BoolVar(&config.verbose, "verbose", false, "Be verbose about progress")
 	flag.BoolVar(&config.dryrun, "dry-run", false, "Only print what images would be downloaded")
+	flag.BoolVar(&config.silent, "silent", false, "Suppress progress bars")
+	flag.BoolVar(&config.silent, "no-progress", false, "Alias for --silent")
+	flag.Int64Var(&config.maxBytes, "max-bytes", 0, "Maximum size in bytes for a single downloaded image; use zero to disable")
+	flag.StringVar(&config.userAgent, "user-agent", httpclient.DefaultUserAgent, "User-Agent string to send with every request")
+	flag.Float64Var(&config.rate, "rate", 0, "Maximum requests per second per host; use zero to disable rate limiting")
+	flag.BoolVar(&config.robots, "robots", false, "Honor robots.txt on the target host")
 	flag.Parse()
 
 	args := flag.Args()
@@ -242,25 +204,35 @@ func createDestinationDir() {
 	}
 }
 
-func initParallelSemaphore() {
-	if config.parallel > 0 {
-		semaphore = make(chan struct{}, config.parallel)
+func initClient() {
+	client = httpclient.New(config.userAgent, config.rate, config.robots)
+}
+
+func initManager() {
+	manager = transfer.NewManager(config.directory, config.parallel, client, config.maxBytes)
+}
+
+func initManifest() {
+	var err error
+
+	manifestStore, err = manifest.Open(config.directory)
+	if err != nil {
+		log.Fatal("Error opening manifest: ", err)
 	}
 }
 
-func startDownloads() {
+func startDownloads(ctx context.Context, renderer *progress.Renderer) {
 	var wg sync.WaitGroup
 
-	urls := make(chan string)
-
-	wg.Add(2)
+	p := selectProvider(config.provider, config.url)
+	pages := p.Pages(ctx, config.url)
 
-	go fetchAllImageUrls(config.url, urls, &wg)
+	wg.Add(1)
 
 	if config.dryrun {
-		go printImages(urls, &wg)
+		go printImages(ctx, p, pages, &wg)
 	} else {
-		go downloadImages(urls, &wg)
+		go downloadImages(ctx, p, pages, renderer, &wg)
 	}
 	wg.Wait()
 }
@@ -271,17 +243,87 @@ var Usage = func() {
 	os.Exit(1)
 }
 
+// watchSignals cancels ctx on SIGINT/SIGTERM and reports, via the
+// returned function, whether that happened.
+func watchSignals(cancel context.CancelFunc) (interrupted func() bool) {
+	var caught atomic.Bool
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigCh
+		log.Print("Interrupted, shutting down...")
+		caught.Store(true)
+		cancel()
+	}()
+
+	return caught.Load
+}
+
+// renderEvents feeds manager's transfer events into r until stop is
+// closed, then drains whatever is already buffered and closes done.
+func renderEvents(m *transfer.Manager, r *progress.Renderer, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case ev := <-m.Events():
+			r.Handle(ev)
+		case <-stop:
+			for {
+				select {
+				case ev := <-m.Events():
+					r.Handle(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func printSummary(s progress.Summary) {
+	fmt.Printf("Completed: %d, Failed: %d, Skipped: %d\n", s.Completed, s.Failed, s.Skipped)
+}
+
 func main() {
 	initDefaults()
 	parseArgs()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupted := watchSignals(cancel)
+
+	initClient()
+
 	if !config.dryrun {
 		createDestinationDir()
+		initManifest()
+		initManager()
+	}
+
+	renderer := progress.NewRenderer(!config.dryrun && !config.silent)
+
+	stopRender := make(chan struct{})
+	renderDone := make(chan struct{})
+	if !config.dryrun {
+		go renderEvents(manager, renderer, stopRender, renderDone)
 	}
 
-	initParallelSemaphore()
+	startDownloads(ctx, renderer)
+
+	if !config.dryrun {
+		close(stopRender)
+		<-renderDone
+		renderer.Finish()
+		printSummary(renderer.Summary)
+	}
 
-	startDownloads()
+	if interrupted() {
+		os.Exit(1)
+	}
 
 	os.Exit(0)
 }
@@ -0,0 +1,91 @@
+// Package manifest records what nagibackup has already downloaded, so
+// that repeated runs against the same directory only fetch new images.
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry describes one previously downloaded image.
+type Entry struct {
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	Filename  string    `json:"filename"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Store is a JSON-backed map of source URL to Entry, persisted under
+// <directory>/.nagibackup/manifest.json.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// Open loads the manifest for directory, creating an empty one if none
+// exists yet.
+func Open(directory string) (*Store, error) {
+	dir := filepath.Join(directory, ".nagibackup")
+	if err := os.MkdirAll(dir, 0740); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		path:    filepath.Join(dir, "manifest.json"),
+		entries: make(map[string]Entry),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Lookup returns the recorded Entry for url, if any.
+func (s *Store) Lookup(url string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[url]
+
+	return e, ok
+}
+
+// Record stores e for url and persists the manifest to disk.
+func (s *Store) Record(url string, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[url] = e
+
+	return s.save()
+}
+
+// save writes the manifest to a temporary file and renames it into
+// place, so a crash mid-write cannot corrupt the existing manifest.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreRecordAndLookupRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	want := Entry{
+		SHA256:    "deadbeef",
+		Size:      1234,
+		Filename:  "image.jpg",
+		FetchedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	if err := s.Record("http://example.com/image.jpg", want); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	got, ok := s.Lookup("http://example.com/image.jpg")
+	if !ok {
+		t.Fatal("Lookup: entry not found")
+	}
+
+	if got != want {
+		t.Errorf("Lookup = %+v, want %+v", got, want)
+	}
+
+	// A fresh Store opened against the same directory must see the
+	// persisted entry too.
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open (reopen): %v", err)
+	}
+
+	got, ok = reopened.Lookup("http://example.com/image.jpg")
+	if !ok {
+		t.Fatal("Lookup after reopen: entry not found")
+	}
+
+	if got != want {
+		t.Errorf("Lookup after reopen = %+v, want %+v", got, want)
+	}
+}
+
+func TestStoreLookupMiss(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if _, ok := s.Lookup("http://example.com/missing.jpg"); ok {
+		t.Error("Lookup: expected a miss for an unrecorded URL")
+	}
+}
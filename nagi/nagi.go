@@ -0,0 +1,182 @@
+// Package nagi implements provider.Provider for nagi.ee image galleries:
+// it walks the paginated "imagelog" listings, follows each entry to its
+// zoom page, and resolves the real image URL from there.
+package nagi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dullgiulio/nagibackup/httpclient"
+	"github.com/dullgiulio/nagibackup/provider"
+)
+
+// DefaultDomain is used to resolve the relative links that nagi.ee pages
+// return for pagination and per-image pages.
+const DefaultDomain = "http://nagi.ee"
+
+// Host is the hostname this provider handles.
+const Host = "nagi.ee"
+
+// Provider archives nagi.ee image galleries.
+type Provider struct {
+	Domain  string
+	Verbose bool
+
+	client *http.Client
+}
+
+// New returns a Provider configured for the default nagi.ee domain,
+// fetching pages through client. When verbose is set, the provider logs
+// page-fetch and link-extraction tracing.
+func New(client *http.Client, verbose bool) *Provider {
+	return &Provider{Domain: DefaultDomain, Verbose: verbose, client: client}
+}
+
+func (p *Provider) document(ctx context.Context, url string) (*goquery.Document, error) {
+	return httpclient.Document(ctx, p.client, url)
+}
+
+func (p *Provider) Pages(ctx context.Context, startURL string) <-chan string {
+	pages := make(chan string)
+
+	go func() {
+		defer close(pages)
+
+		url := startURL
+		for url != "" {
+			if p.Verbose {
+				log.Print("Fetching links from ", url)
+			}
+
+			select {
+			case pages <- url:
+			case <-ctx.Done():
+				return
+			}
+
+			doc, err := p.document(ctx, url)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Fatal("Error opening document with single image: ", err)
+			}
+
+			if next := p.extractNextURL(doc); next == url {
+				break
+			} else {
+				url = next
+			}
+		}
+	}()
+
+	return pages
+}
+
+func (p *Provider) ImagesOnPage(ctx context.Context, url string) []provider.ImageRef {
+	doc, err := p.document(ctx, url)
+	if err != nil {
+		log.Print("Error opening document with all images: ", err.Error())
+		return nil
+	}
+
+	var refs []provider.ImageRef
+
+	doc.Find("div.imagelog p a").Each(func(i int, s *goquery.Selection) {
+		val, ok := s.Attr("href")
+		if !ok {
+			if p.Verbose {
+				log.Print("Href not found, skipping")
+			}
+			return
+		}
+
+		refs = append(refs, p.zoomRefs(ctx, val)...)
+	})
+
+	return refs
+}
+
+// zoomRefs fetches the per-set page at setURL and returns the zoom
+// links for every original-size image it lists.
+func (p *Provider) zoomRefs(ctx context.Context, setURL string) []provider.ImageRef {
+	doc, err := p.document(ctx, setURL)
+	if err != nil {
+		log.Print("Error opening document with all images: ", err.Error())
+		return nil
+	}
+
+	var refs []provider.ImageRef
+
+	doc.Find("#zoom ul li a").Each(func(i int, s *goquery.Selection) {
+		val, ok := s.Attr("href")
+		if !ok {
+			if p.Verbose {
+				log.Print("Href not found, skipping")
+			}
+			return
+		}
+
+		// TODO: Select based on setting in config.size
+		if !strings.Contains(val, "size=o") {
+			return
+		}
+
+		refs = append(refs, provider.ImageRef{URL: p.Domain + val})
+	})
+
+	return refs
+}
+
+func (p *Provider) ResolveImage(ctx context.Context, ref provider.ImageRef) (string, error) {
+	doc, err := p.document(ctx, ref.URL)
+	if err != nil {
+		return "", fmt.Errorf("opening document for single image: %w", err)
+	}
+
+	var image string
+
+	doc.Find("div table").Each(func(i int, s *goquery.Selection) {
+		el := s.Parent().Find("img")
+		if el == nil {
+			return
+		}
+
+		if val, ok := el.Attr("src"); ok {
+			image = val
+		}
+	})
+
+	if image == "" {
+		return "", fmt.Errorf("no image found on page %s", ref.URL)
+	}
+
+	return image, nil
+}
+
+func (p *Provider) extractNextURL(doc *goquery.Document) (nextURL string) {
+	doc.Find("div.pager a.navi").Each(func(i int, s *goquery.Selection) {
+		val, ok := s.Attr("id")
+		if !ok {
+			return
+		}
+
+		if !strings.HasPrefix(val, "next_pager_") {
+			return
+		}
+
+		pageNextURL, ok := s.Attr("href")
+		if !ok {
+			log.Print("Invalid pager link")
+		}
+
+		nextURL = p.Domain + pageNextURL
+	})
+
+	return
+}
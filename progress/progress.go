@@ -0,0 +1,105 @@
+// Package progress renders transfer.Manager events as progress bars and
+// tallies a summary of how each observed transfer ended.
+package progress
+
+import (
+	"context"
+	"path"
+	"sync/atomic"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/dullgiulio/nagibackup/transfer"
+)
+
+// Summary counts how each observed transfer ended.
+type Summary struct {
+	Completed int
+	Failed    int
+	Skipped   int
+}
+
+// Renderer consumes transfer.Event values, driving an overall bar
+// (total images discovered/finished) and a per-file bar (bytes of the
+// currently active download), unless disabled.
+type Renderer struct {
+	enabled bool
+
+	overall    *pb.ProgressBar
+	file       *pb.ProgressBar
+	discovered int64 // atomic; total images discovered so far
+
+	Summary Summary
+}
+
+// NewRenderer returns a Renderer. When enabled is false, Handle still
+// tallies Summary but prints nothing.
+func NewRenderer(enabled bool) *Renderer {
+	r := &Renderer{enabled: enabled}
+
+	if enabled {
+		r.overall = pb.New(0)
+		r.overall.SetTemplateString(`images {{counters . }} {{bar . }} {{percent . }}`)
+		r.overall.Start()
+
+		r.file = pb.New64(0)
+		r.file.Set(pb.Bytes, true)
+		r.file.Start()
+	}
+
+	return r
+}
+
+// Discovered bumps the overall bar's total by n, the number of image
+// URLs just found by the crawl. Unlike Handle, it is safe to call
+// concurrently with Handle and with itself, since discovery and
+// transfer events are produced by different goroutines.
+func (r *Renderer) Discovered(n int) {
+	if !r.enabled || n == 0 {
+		return
+	}
+
+	r.overall.SetTotal(atomic.AddInt64(&r.discovered, int64(n)))
+}
+
+// Handle updates the bars and Summary for a single event. Feed events
+// from a single goroutine; Handle is not safe for concurrent use.
+func (r *Renderer) Handle(ev transfer.Event) {
+	switch ev.Type {
+	case transfer.EventStarted:
+		if r.enabled {
+			r.file.SetTotal(ev.Total)
+			r.file.SetCurrent(0)
+			r.file.Set("prefix", path.Base(ev.URL)+" ")
+		}
+	case transfer.EventProgress:
+		if r.enabled {
+			r.file.SetCurrent(ev.Bytes)
+		}
+	case transfer.EventCompleted:
+		r.Summary.Completed++
+		r.finishOne()
+	case transfer.EventFailed:
+		if ev.Err == context.Canceled {
+			r.Summary.Skipped++
+		} else {
+			r.Summary.Failed++
+		}
+		r.finishOne()
+	}
+}
+
+func (r *Renderer) finishOne() {
+	if r.enabled {
+		r.overall.Increment()
+	}
+}
+
+// Finish stops the bars, if any were started.
+func (r *Renderer) Finish() {
+	if !r.enabled {
+		return
+	}
+
+	r.file.Finish()
+	r.overall.Finish()
+}
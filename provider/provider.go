@@ -0,0 +1,32 @@
+// Package provider defines the interface that site-specific gallery
+// scrapers implement, so that nagibackup can archive images from more
+// than one site instead of being hardcoded to nagi.ee.
+package provider
+
+import "context"
+
+// ImageRef identifies an image found on a page. What it contains is up
+// to the Provider that produced it: it may already be the final
+// downloadable URL, or it may need ResolveImage to turn it into one.
+type ImageRef struct {
+	URL  string
+	Name string
+}
+
+// Provider knows how to walk the pages of a single gallery site and
+// resolve the final, downloadable URL for each image found on them.
+// Every method takes a context.Context so a crawl can be cancelled
+// promptly, mid-pagination, instead of only between downloads.
+type Provider interface {
+	// Pages yields each listing page URL to visit, starting from
+	// startURL, following pagination until there are no more pages or
+	// ctx is cancelled. The channel is closed in either case.
+	Pages(ctx context.Context, startURL string) <-chan string
+
+	// ImagesOnPage returns the image references found on the given
+	// listing page URL.
+	ImagesOnPage(ctx context.Context, url string) []ImageRef
+
+	// ResolveImage turns an ImageRef into the final URL to download.
+	ResolveImage(ctx context.Context, ref ImageRef) (string, error)
+}
@@ -0,0 +1,134 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxAttempts  = 5
+	initialDelay = 100 * time.Millisecond
+	maxDelay     = 5 * time.Second
+)
+
+// statusErr is the error statusError returns for a non-2xx response,
+// annotated with the response's Retry-After hint when present so
+// withBackoff can honor it.
+type statusErr struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusErr) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d", e.code)
+}
+
+// permanentErr marks a local/logic failure (bad request, response too
+// large, ...) that withBackoff must never retry: retrying it can only
+// reproduce the same outcome while wasting bandwidth and disk I/O.
+type permanentErr struct {
+	err error
+}
+
+func (e *permanentErr) Error() string { return e.err.Error() }
+func (e *permanentErr) Unwrap() error { return e.err }
+
+// permanent wraps err so isTransient treats it as non-retryable.
+func permanent(err error) error {
+	return &permanentErr{err: err}
+}
+
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return &statusErr{code: resp.StatusCode, retryAfter: retryAfter(resp)}
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(v); err == nil {
+		return time.Until(when)
+	}
+
+	return 0
+}
+
+// isTransient reports whether err is worth retrying: a 5xx or 429
+// status, or a network-level failure. Errors
+// explicitly marked permanent (via permanent) never retry, regardless
+// of their underlying shape.
+func isTransient(err error) bool {
+	var pe *permanentErr
+	if errors.As(err, &pe) {
+		return false
+	}
+
+	var se *statusErr
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+
+	// Network errors (timeouts, connection resets, DNS, ...) are
+	// treated as transient; anything else is a local/logic failure.
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withBackoff calls fn up to maxAttempts times in total, with exponential
+// backoff between 100ms and 5s, honoring any Retry-After duration
+// carried by a *statusErr.
+func withBackoff(ctx context.Context, fn func() (int64, error)) (int64, error) {
+	delay := initialDelay
+
+	var lastErr error
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		n, err := fn()
+		if err == nil {
+			return n, nil
+		}
+
+		lastErr = err
+
+		if !isTransient(err) || attempt == maxAttempts {
+			return 0, err
+		}
+
+		wait := delay
+		if se, ok := err.(*statusErr); ok && se.retryAfter > 0 {
+			wait = se.retryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	return 0, lastErr
+}
@@ -0,0 +1,101 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"5xx status", &statusErr{code: http.StatusBadGateway}, true},
+		{"429 status", &statusErr{code: http.StatusTooManyRequests}, true},
+		{"4xx status", &statusErr{code: http.StatusNotFound}, false},
+		{"network error", &net.DNSError{Err: "no such host", IsTimeout: true}, true},
+		{"permanent error", permanent(errors.New("max-bytes exceeded")), false},
+		{"wrapped permanent error", &statusErrWrapper{permanent(errors.New("bad request"))}, false},
+		{"plain local error", errors.New("something went wrong locally"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isTransient(c.err); got != c.want {
+				t.Errorf("isTransient(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+// statusErrWrapper exercises errors.As unwrapping through a layer that
+// isn't itself a *statusErr or *permanentErr.
+type statusErrWrapper struct {
+	err error
+}
+
+func (w *statusErrWrapper) Error() string { return w.err.Error() }
+func (w *statusErrWrapper) Unwrap() error { return w.err }
+
+func TestWithBackoffRetriesTransientThenSucceeds(t *testing.T) {
+	attempts := 0
+
+	n, err := withBackoff(context.Background(), func() (int64, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, &statusErr{code: http.StatusServiceUnavailable}
+		}
+
+		return 42, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 42 {
+		t.Errorf("n = %d, want 42", n)
+	}
+
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestWithBackoffDoesNotRetryPermanentErrors(t *testing.T) {
+	attempts := 0
+	wantErr := permanent(errors.New("response exceeds max-bytes limit"))
+
+	_, err := withBackoff(context.Background(), func() (int64, error) {
+		attempts++
+		return 0, wantErr
+	})
+
+	if !errors.Is(err, wantErr) {
+		t.Errorf("err = %v, want %v", err, wantErr)
+	}
+
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (permanent errors must not retry)", attempts)
+	}
+}
+
+func TestWithBackoffGivesUpAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+
+	_, err := withBackoff(context.Background(), func() (int64, error) {
+		attempts++
+		return 0, &statusErr{code: http.StatusBadGateway}
+	})
+
+	if err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+
+	if attempts != maxAttempts {
+		t.Errorf("attempts = %d, want %d", attempts, maxAttempts)
+	}
+}
@@ -0,0 +1,248 @@
+// Package transfer implements a small download manager modelled after
+// Docker's image download manager: a bounded worker pool that
+// deduplicates concurrent requests for the same URL, retries transient
+// failures with backoff, and streams progress events for the caller to
+// render.
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// EventType identifies what stage of a transfer an Event describes.
+type EventType int
+
+const (
+	EventStarted EventType = iota
+	EventProgress
+	EventCompleted
+	EventFailed
+)
+
+// Event reports the progress of a single URL's transfer.
+type Event struct {
+	Type  EventType
+	URL   string
+	Bytes int64 // bytes copied so far, for EventProgress/EventCompleted
+	Total int64 // content length, if known; zero if unknown
+	Err   error // set for EventFailed
+}
+
+// Result describes a successfully downloaded file.
+type Result struct {
+	Path   string
+	SHA256 string
+	Size   int64
+}
+
+// transfer tracks the one in-flight download for a given URL, so that
+// concurrent requests for it can share the result instead of each
+// issuing their own HTTP GET.
+type transfer struct {
+	done   chan struct{}
+	result Result
+	err    error
+}
+
+// Manager downloads URLs into a directory using a bounded pool of
+// workers, deduplicating concurrent requests for the same URL.
+type Manager struct {
+	client   *http.Client
+	baseDir  string
+	maxBytes int64
+	events   chan Event
+
+	sem chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]*transfer
+}
+
+// NewManager returns a Manager that stores downloads under baseDir. If
+// client is nil, http.DefaultClient is used. maxBytes caps how large a
+// single download may grow before it is aborted; zero means unlimited.
+// workers caps how many transfers run concurrently; zero or negative
+// means unlimited, matching the longstanding --parallel 0 meaning.
+func NewManager(baseDir string, workers int, client *http.Client, maxBytes int64) *Manager {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var sem chan struct{}
+	if workers > 0 {
+		sem = make(chan struct{}, workers)
+	}
+
+	return &Manager{
+		client:   client,
+		baseDir:  baseDir,
+		maxBytes: maxBytes,
+		events:   make(chan Event, 64),
+		sem:      sem,
+		inflight: make(map[string]*transfer),
+	}
+}
+
+// Events returns the channel of transfer events. It is never closed, so
+// callers should select on it alongside their own termination signal.
+func (m *Manager) Events() <-chan Event {
+	return m.events
+}
+
+// Download fetches url, streaming it to a ".part" file under the
+// manager's base directory before atomically renaming it into place on
+// success. Concurrent calls for the same url share one in-flight
+// transfer and its result.
+func (m *Manager) Download(ctx context.Context, url string) (Result, error) {
+	t, leader := m.claim(url)
+	if !leader {
+		<-t.done
+		return t.result, t.err
+	}
+
+	t.result, t.err = m.run(ctx, url)
+	close(t.done)
+
+	m.mu.Lock()
+	delete(m.inflight, url)
+	m.mu.Unlock()
+
+	return t.result, t.err
+}
+
+// claim registers url as in-flight and reports whether the caller is
+// the leader responsible for actually performing the transfer.
+func (m *Manager) claim(url string) (*transfer, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.inflight[url]; ok {
+		return t, false
+	}
+
+	t := &transfer{done: make(chan struct{})}
+	m.inflight[url] = t
+
+	return t, true
+}
+
+func (m *Manager) run(ctx context.Context, url string) (Result, error) {
+	if m.sem != nil {
+		m.sem <- struct{}{}
+		defer func() { <-m.sem }()
+	}
+
+	m.events <- Event{Type: EventStarted, URL: url}
+
+	destination := filepath.Join(m.baseDir, path.Base(url))
+	partPath := destination + ".part"
+
+	var sum string
+
+	size, err := withBackoff(ctx, func() (int64, error) {
+		var written int64
+		var ferr error
+
+		written, sum, ferr = m.fetchOnce(ctx, url, partPath)
+
+		return written, ferr
+	})
+	if err != nil {
+		os.Remove(partPath)
+		m.events <- Event{Type: EventFailed, URL: url, Err: err}
+		return Result{}, err
+	}
+
+	if err := os.Rename(partPath, destination); err != nil {
+		m.events <- Event{Type: EventFailed, URL: url, Err: err}
+		return Result{}, err
+	}
+
+	m.events <- Event{Type: EventCompleted, URL: url, Bytes: size}
+
+	return Result{Path: destination, SHA256: sum, Size: size}, nil
+}
+
+// fetchOnce performs a single GET of url, streaming the body to
+// partPath while hashing it and reporting progress events as it goes.
+// It fails once more than m.maxBytes have been read, if maxBytes is
+// set, so that a runaway response cannot fill the disk.
+func (m *Manager) fetchOnce(ctx context.Context, url, partPath string) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", permanent(fmt.Errorf("building request: %w", err))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if err := statusError(resp); err != nil {
+		return 0, "", err
+	}
+
+	out, err := os.Create(partPath)
+	if err != nil {
+		return 0, "", permanent(err)
+	}
+	defer out.Close()
+
+	total := resp.ContentLength
+	if total < 0 {
+		total = 0
+	}
+
+	progress := &progressWriter{
+		events: m.events,
+		url:    url,
+		total:  total,
+	}
+
+	hash := sha256.New()
+
+	body := io.Reader(resp.Body)
+	if m.maxBytes > 0 {
+		body = io.LimitReader(resp.Body, m.maxBytes+1)
+	}
+
+	written, err := io.Copy(io.MultiWriter(out, hash), io.TeeReader(body, progress))
+	if err != nil {
+		return written, "", err
+	}
+
+	if m.maxBytes > 0 && written > m.maxBytes {
+		return written, "", permanent(fmt.Errorf("response for %s exceeds max-bytes limit of %d", url, m.maxBytes))
+	}
+
+	if err := out.Sync(); err != nil {
+		return written, "", permanent(err)
+	}
+
+	return written, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// progressWriter emits an EventProgress for every chunk io.Copy reads,
+// via io.TeeReader.
+type progressWriter struct {
+	events chan<- Event
+	url    string
+	total  int64
+	copied int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.copied += int64(len(p))
+	w.events <- Event{Type: EventProgress, URL: w.url, Bytes: w.copied, Total: w.total}
+	return len(p), nil
+}
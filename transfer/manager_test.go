@@ -0,0 +1,88 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestManagerDownloadDedupesConcurrentRequests(t *testing.T) {
+	var hits int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	m := NewManager(t.TempDir(), 4, server.Client(), 0)
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	results := make([]Result, n)
+	errs := make([]error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = m.Download(context.Background(), server.URL+"/image.bin")
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&hits); got != 1 {
+		t.Errorf("server received %d hits, want exactly 1 for %d concurrent requests of the same URL", got, n)
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Download[%d] returned error: %v", i, err)
+		}
+
+		if results[i] != results[0] {
+			t.Errorf("Download[%d] = %+v, want %+v (all callers should share the one result)", i, results[i], results[0])
+		}
+	}
+}
+
+func TestManagerDownloadAbortsOnContextCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	m := NewManager(t.TempDir(), 4, server.Client(), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := m.Download(ctx, server.URL+"/slow.bin")
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected an error from a cancelled download")
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want it to wrap context.Canceled", err)
+	}
+
+	if elapsed > 2*time.Second {
+		t.Errorf("Download took %s to abort after cancellation, want it to return promptly", elapsed)
+	}
+}